@@ -18,17 +18,32 @@ import (
 	"flag"
 	"os/user"
 	"strings"
+	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/google/gapid/core/app"
 	"github.com/google/gapid/core/context/jot"
 	"github.com/google/gapid/core/data/search/script"
+	"github.com/google/gapid/core/data/stash"
 	"github.com/google/gapid/core/fault/cause"
 	"github.com/google/gapid/core/git"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/net/grpcutil"
 	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/os/registry"
 	"github.com/google/gapid/test/robot/build"
+	"github.com/google/gapid/test/robot/build/audit"
+	_ "github.com/google/gapid/test/robot/build/audit/file"
+	_ "github.com/google/gapid/test/robot/build/audit/grpcsink"
+	_ "github.com/google/gapid/test/robot/build/audit/syslog"
+	"github.com/google/gapid/test/robot/build/ci"
+	"github.com/google/gapid/test/robot/build/notifier"
+	_ "github.com/google/gapid/test/robot/build/notifier/grpcstream"
+	_ "github.com/google/gapid/test/robot/build/notifier/http"
+	_ "github.com/google/gapid/test/robot/build/notifier/slack"
+	"github.com/google/gapid/test/robot/build/sourcedate"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"google.golang.org/grpc"
 )
 
@@ -41,6 +56,27 @@ var buildFlags struct {
 	uploader    string
 	name        string
 	pkg         string
+	registry    string
+	sourceDate  string
+	auditSink   string
+	auditConfig string
+	url         string
+	format      string
+	secret      string
+}
+
+func addAuditFlags(verb *app.Verb) {
+	verb.Flags.Raw.StringVar(&buildFlags.auditSink, "audit-sink", "", "The audit sink kind to emit events to: file, grpc, or syslog")
+	verb.Flags.Raw.StringVar(&buildFlags.auditConfig, "audit-config", "", "The configuration passed to the audit sink, e.g. a file path or address")
+}
+
+// newAuditSink constructs the configured audit sink, or nil if none was
+// requested.
+func newAuditSink(ctx log.Context) (audit.Sink, error) {
+	if buildFlags.auditSink == "" {
+		return nil, nil
+	}
+	return audit.New(ctx, buildFlags.auditSink, buildFlags.auditConfig)
 }
 
 func init() {
@@ -55,6 +91,10 @@ func init() {
 	buildUpload.Flags.Raw.StringVar(&buildFlags.branch, "branch", "", "The build branch, will be guessed if not set")
 	buildUpload.Flags.Raw.StringVar(&buildFlags.description, "description", "", "An optional build description")
 	buildUpload.Flags.Raw.StringVar(&buildFlags.uploader, "uploader", "", "The uploading entity, will be guessed if not set")
+	buildUpload.Flags.Raw.StringVar(&buildFlags.registry, "registry", "", "An OCI registry reference to mirror this package to, e.g. ghcr.io/org/gapid-captures")
+	buildUpload.Flags.Raw.StringVar(&buildFlags.sourceDate, "source-date", "", "Timestamp mode for reproducible builds: Zero, SourceTimestamp, BuildTimestamp, or an RFC3339 timestamp")
+	addS3Flags(buildUpload)
+	addAuditFlags(buildUpload)
 	uploadVerb.Add(buildUpload)
 	artifactSearch := &app.Verb{
 		Name:       "artifact",
@@ -86,18 +126,66 @@ func init() {
 	trackSet.Flags.Raw.StringVar(&buildFlags.name, "name", "", "The new name for the track")
 	trackSet.Flags.Raw.StringVar(&buildFlags.description, "description", "", "A description of the track")
 	trackSet.Flags.Raw.StringVar(&buildFlags.pkg, "package", "", "The id of the package at the head of the track")
+	addAuditFlags(trackSet)
 	setVerb.Add(trackSet)
+	webhookSearch := &app.Verb{
+		Name:       "webhook",
+		ShortHelp:  "List webhooks registered on the server",
+		ShortUsage: "<query>",
+		Run:        doWebhookSearch,
+	}
+	searchVerb.Add(webhookSearch)
+	webhookSet := &app.Verb{
+		Name:       "webhook",
+		ShortHelp:  "Sets values on a webhook",
+		ShortUsage: "<id or name>",
+		Run:        doWebhookUpdate,
+	}
+	webhookSet.Flags.Raw.StringVar(&buildFlags.name, "name", "", "The new name for the webhook")
+	webhookSet.Flags.Raw.StringVar(&buildFlags.url, "url", "", "The URL to deliver the webhook to")
+	webhookSet.Flags.Raw.StringVar(&buildFlags.format, "format", "http", "The delivery format: http, slack, or grpc")
+	webhookSet.Flags.Raw.StringVar(&buildFlags.secret, "secret", "", "The HMAC secret used to sign deliveries")
+	setVerb.Add(webhookSet)
 }
 
 type buildUploader struct {
-	store build.Store
-	info  *build.Information
+	store     build.Store
+	stash     stash.Store
+	audit     audit.Sink
+	info      *build.Information
+	setID     string
+	artifacts []string
+	layers    []v1.Layer
 }
 
 func (u *buildUploader) prepare(ctx log.Context, conn *grpc.ClientConn) error {
-	// see if we can find a git cl in the cwd
 	typ := build.BuildBot
-	if g, err := git.New("."); err != nil {
+	var clTimestamp time.Time
+	var ciInfo *ci.Info
+	if info, ok := ci.Detect(); ok {
+		// Running under a hosted CI system: its environment is more
+		// reliable than probing a possibly shallow git checkout.
+		ciInfo = info
+		ctx.Raw("").Logf("Detected CI provider %s", info.Provider)
+		if buildFlags.cl == "" {
+			buildFlags.cl = info.Commit
+		}
+		if buildFlags.branch == "" {
+			buildFlags.branch = info.Branch
+		}
+		if buildFlags.uploader == "" {
+			buildFlags.uploader = info.Actor
+		}
+		// The checkout may still be shallow or detached, but git can
+		// usually still date the commit CI just checked out, and
+		// --source-date SourceTimestamp needs that regardless of which
+		// source supplied cl/branch/uploader.
+		if g, err := git.New("."); err == nil {
+			if cl, err := g.HeadCL(ctx); err == nil {
+				clTimestamp = cl.Timestamp
+			}
+		}
+	} else if g, err := git.New("."); err != nil {
 		jot.Notice(ctx).Cause(err).Print("Git failed")
 	} else {
 		typ = build.User
@@ -114,6 +202,7 @@ func (u *buildUploader) prepare(ctx log.Context, conn *grpc.ClientConn) error {
 				buildFlags.description = cl.Subject
 				ctx.Raw("").Logf("Detected description %s", buildFlags.description)
 			}
+			clTimestamp = cl.Timestamp
 		}
 		if status, err := g.Status(ctx); err != nil {
 			jot.Notice(ctx).Cause(err).Print("Status failed")
@@ -141,6 +230,33 @@ func (u *buildUploader) prepare(ctx log.Context, conn *grpc.ClientConn) error {
 	}
 	ctx.Raw("").Logf("Dectected build type %s", typ)
 	u.store = build.NewRemote(ctx, conn)
+	sink, err := newAuditSink(ctx)
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed constructing audit sink")
+	}
+	u.audit = sink
+	s, err := newStashStore(ctx, conn)
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed connecting to stash")
+	}
+	u.stash = s
+	mode, explicit, err := sourcedate.Resolve(ctx, buildFlags.sourceDate)
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now()
+	switch mode {
+	case sourcedate.Zero:
+		timestamp = time.Unix(0, 0).UTC()
+	case sourcedate.SourceTimestamp:
+		timestamp = clTimestamp
+	case sourcedate.Explicit:
+		timestamp = explicit
+	}
+	ts, err := ptypes.TimestampProto(timestamp)
+	if err != nil {
+		return cause.Explain(ctx, err, "Invalid source date")
+	}
 	host := device.Host(ctx)
 	u.info = &build.Information{
 		Type:        typ,
@@ -150,27 +266,109 @@ func (u *buildUploader) prepare(ctx log.Context, conn *grpc.ClientConn) error {
 		Description: buildFlags.description,
 		Builder:     host,
 		Uploader:    buildFlags.uploader,
+		Timestamp:   ts,
+	}
+	if ciInfo != nil {
+		u.info.CiProvider = ciInfo.Provider
+		u.info.CiBuildUrl = ciInfo.BuildURL
 	}
 	return nil
 }
 
-func (u *buildUploader) process(ctx log.Context, id string) error {
-	id, merged, err := u.store.Add(ctx, id, u.info)
+func (u *buildUploader) process(ctx log.Context, filename string) error {
+	fileID, err := u.stash.UploadFile(ctx, filename)
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed uploading to stash").With("filename", filename)
+	}
+	setID, merged, err := u.store.Add(ctx, fileID, u.info)
 	if err != nil {
 		return cause.Explain(ctx, err, "Failed processing build")
 	}
 	if merged {
-		ctx.Raw("").Logf("Merged with build set %s", id)
+		ctx.Raw("").Logf("Merged with build set %s", setID)
 	} else {
-		ctx.Raw("").Logf("New build set %s", id)
+		ctx.Raw("").Logf("New build set %s", setID)
+		notifyWebhooks(ctx, u.store, &notifier.Payload{
+			Event:       "build",
+			Info:        u.info,
+			ArtifactIds: []string{fileID},
+		})
+	}
+	if u.audit != nil {
+		if err := u.audit.Write(ctx, &audit.Event{
+			Action: "store.Add",
+			Who:    u.info.Uploader,
+			When:   time.Now().UTC().Format(time.RFC3339),
+			Cl:     u.info.Cl,
+			Id:     setID,
+			Merged: merged,
+			Type:   u.info.Type,
+		}); err != nil {
+			jot.Notice(ctx).Cause(err).Print("Failed writing audit event")
+		}
+	}
+	if buildFlags.registry != "" {
+		// Each upload invocation processes one file in the build set.
+		// Keep the already-built layer for every file seen so far around
+		// rather than re-opening the stash for files pushed by an earlier
+		// call, and push the whole set as one manifest rather than one
+		// image per file. A file that lands in a different build set starts
+		// a new set of layers for that set's tag.
+		if setID != u.setID {
+			u.setID = setID
+			u.artifacts = nil
+			u.layers = nil
+		}
+		u.artifacts = append(u.artifacts, fileID)
+		layer, err := registry.Layer(ctx, u.stash, fileID)
+		if err != nil {
+			return cause.Explain(ctx, err, "Failed preparing registry layer").With("id", fileID)
+		}
+		u.layers = append(u.layers, layer)
+		if err := registry.Push(ctx, buildFlags.registry+":"+setID, u.info, u.layers); err != nil {
+			return cause.Explain(ctx, err, "Failed mirroring to registry")
+		}
+		ctx.Raw("").Logf("Mirrored %s to %s", setID, buildFlags.registry)
+	}
+	return nil
+}
+
+// registryRef returns the OCI registry reference embedded in expression and
+// true, if expression names a registry to search instead of the server.
+func registryRef(expression string) (string, bool) {
+	const prefix = "registry:"
+	if !strings.HasPrefix(expression, prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(expression, prefix)), true
+}
+
+// listRegistryManifests writes the manifest of every tag at ref to ctx's
+// output, for the "registry:" search form shared by doArtifactSearch and
+// doPackageSearch.
+func listRegistryManifests(ctx log.Context, ref string) error {
+	out := ctx.Raw("").Writer()
+	tags, err := registry.List(ctx, ref)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		m, err := registry.Manifest(ctx, ref+":"+tag)
+		if err != nil {
+			return err
+		}
+		out.Write(m)
 	}
 	return nil
 }
 
 func doArtifactSearch(ctx log.Context, flags flag.FlagSet) error {
+	expression := strings.Join(flags.Args(), " ")
+	if ref, ok := registryRef(expression); ok {
+		return listRegistryManifests(ctx, ref)
+	}
 	return grpcutil.Client(ctx, serverAddress, func(ctx log.Context, conn *grpc.ClientConn) error {
 		b := build.NewRemote(ctx, conn)
-		expression := strings.Join(flags.Args(), " ")
 		out := ctx.Raw("").Writer()
 		expr, err := script.Parse(ctx, expression)
 		if err != nil {
@@ -184,9 +382,12 @@ func doArtifactSearch(ctx log.Context, flags flag.FlagSet) error {
 }
 
 func doPackageSearch(ctx log.Context, flags flag.FlagSet) error {
+	expression := strings.Join(flags.Args(), " ")
+	if ref, ok := registryRef(expression); ok {
+		return listRegistryManifests(ctx, ref)
+	}
 	return grpcutil.Client(ctx, serverAddress, func(ctx log.Context, conn *grpc.ClientConn) error {
 		b := build.NewRemote(ctx, conn)
-		expression := strings.Join(flags.Args(), " ")
 		out := ctx.Raw("").Writer()
 		expr, err := script.Parse(ctx, expression)
 		if err != nil {
@@ -216,9 +417,26 @@ func doTrackSearch(ctx log.Context, flags flag.FlagSet) error {
 }
 
 var (
-	idOrName = script.MustParse("Id == $ or Name == $").Using("$")
+	idOrName    = script.MustParse("Id == $ or Name == $").Using("$")
+	allWebhooks = script.MustParse("true")
 )
 
+// notifyWebhooks delivers payload to every webhook registered on b,
+// logging rather than failing the calling operation when a delivery can't
+// be made: a webhook outage shouldn't be able to block an upload or track
+// update.
+func notifyWebhooks(ctx log.Context, b build.Store, payload *notifier.Payload) {
+	err := b.SearchWebhooks(ctx, allWebhooks.Query(), func(ctx log.Context, webhook *build.Webhook) error {
+		if err := notifier.Notify(ctx, webhook, payload); err != nil {
+			jot.Notice(ctx).Cause(err).Print("Failed delivering webhook")
+		}
+		return nil
+	})
+	if err != nil {
+		jot.Notice(ctx).Cause(err).Print("Failed searching webhooks")
+	}
+}
+
 func doTrackUpdate(ctx log.Context, flags flag.FlagSet) error {
 	return grpcutil.Client(ctx, serverAddress, func(ctx log.Context, conn *grpc.ClientConn) error {
 		b := build.NewRemote(ctx, conn)
@@ -228,6 +446,7 @@ func doTrackUpdate(ctx log.Context, flags flag.FlagSet) error {
 			Description: buildFlags.description,
 			Head:        buildFlags.pkg,
 		}
+		var before *build.Track
 		if len(args) != 0 {
 			// Updating an existing track, find it first
 			err := b.SearchTracks(ctx, idOrName(args[0]).Query(), func(ctx log.Context, entry *build.Track) error {
@@ -235,6 +454,7 @@ func doTrackUpdate(ctx log.Context, flags flag.FlagSet) error {
 					return cause.Explain(ctx, nil, "Multiple tracks matched")
 				}
 				track.Id = entry.Id
+				before = entry
 				return nil
 			})
 			if err != nil {
@@ -249,6 +469,81 @@ func doTrackUpdate(ctx log.Context, flags flag.FlagSet) error {
 			return err
 		}
 		ctx.Raw("").Log(track.String())
+		previousHead := ""
+		if before != nil {
+			previousHead = before.Head
+		}
+		if previousHead != track.Head {
+			notifyWebhooks(ctx, b, &notifier.Payload{
+				Event:        "track",
+				Track:        track,
+				PreviousHead: previousHead,
+			})
+		}
+		if sink, err := newAuditSink(ctx); err != nil {
+			return cause.Explain(ctx, err, "Failed constructing audit sink")
+		} else if sink != nil {
+			if err := sink.Write(ctx, &audit.Event{
+				Action: "UpdateTrack",
+				When:   time.Now().UTC().Format(time.RFC3339),
+				Id:     track.Id,
+				Before: before,
+				After:  track,
+			}); err != nil {
+				jot.Notice(ctx).Cause(err).Print("Failed writing audit event")
+			}
+		}
+		return nil
+	}, grpc.WithInsecure())
+}
+
+func doWebhookSearch(ctx log.Context, flags flag.FlagSet) error {
+	return grpcutil.Client(ctx, serverAddress, func(ctx log.Context, conn *grpc.ClientConn) error {
+		b := build.NewRemote(ctx, conn)
+		expression := strings.Join(flags.Args(), " ")
+		out := ctx.Raw("").Writer()
+		expr, err := script.Parse(ctx, expression)
+		if err != nil {
+			return cause.Explain(ctx, err, "Malformed search query")
+		}
+		return b.SearchWebhooks(ctx, expr.Query(), func(ctx log.Context, entry *build.Webhook) error {
+			proto.MarshalText(out, entry)
+			return nil
+		})
+	}, grpc.WithInsecure())
+}
+
+func doWebhookUpdate(ctx log.Context, flags flag.FlagSet) error {
+	return grpcutil.Client(ctx, serverAddress, func(ctx log.Context, conn *grpc.ClientConn) error {
+		b := build.NewRemote(ctx, conn)
+		args := flags.Args()
+		webhook := &build.Webhook{
+			Name:   buildFlags.name,
+			Url:    buildFlags.url,
+			Format: buildFlags.format,
+			Secret: buildFlags.secret,
+		}
+		if len(args) != 0 {
+			// Updating an existing webhook, find it first
+			err := b.SearchWebhooks(ctx, idOrName(args[0]).Query(), func(ctx log.Context, entry *build.Webhook) error {
+				if webhook.Id != "" {
+					return cause.Explain(ctx, nil, "Multiple webhooks matched")
+				}
+				webhook.Id = entry.Id
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if webhook.Id == "" {
+				return cause.Explain(ctx, nil, "No webhooks matched")
+			}
+		}
+		webhook, err := b.UpdateWebhook(ctx, webhook)
+		if err != nil {
+			return err
+		}
+		ctx.Raw("").Log(webhook.String())
 		return nil
 	}, grpc.WithInsecure())
 }
@@ -23,19 +23,37 @@ import (
 	"github.com/google/gapid/core/data/search/script"
 	"github.com/google/gapid/core/data/stash"
 	stashgrpc "github.com/google/gapid/core/data/stash/grpc"
+	"github.com/google/gapid/core/data/stash/s3store"
 	"github.com/google/gapid/core/fault/cause"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/net/grpcutil"
 	"google.golang.org/grpc"
 )
 
+var s3Flags struct {
+	endpoint  string
+	bucket    string
+	region    string
+	pathStyle bool
+	acl       string
+}
+
+func addS3Flags(verb *app.Verb) {
+	verb.Flags.Raw.StringVar(&s3Flags.endpoint, "s3-endpoint", "", "The S3-compatible endpoint, empty for AWS S3")
+	verb.Flags.Raw.StringVar(&s3Flags.bucket, "s3-bucket", "", "The bucket to store artifacts in, enables S3 storage")
+	verb.Flags.Raw.StringVar(&s3Flags.region, "s3-region", "", "The bucket's region")
+	verb.Flags.Raw.BoolVar(&s3Flags.pathStyle, "s3-path-style", false, "Force path-style bucket addressing")
+	verb.Flags.Raw.StringVar(&s3Flags.acl, "s3-acl", "", "The canned ACL to apply to uploaded objects")
+}
+
 func init() {
 	stashUpload := &app.Verb{
 		Name:       "stash",
 		ShortHelp:  "Upload a file to the stash",
 		ShortUsage: "<filenames>",
-		Run:        doUpload(stashUploader{}),
+		Run:        doUpload(&stashUploader{}),
 	}
+	addS3Flags(stashUpload)
 	uploadVerb.Add(stashUpload)
 	stashSearch := &app.Verb{
 		Name:       "stash",
@@ -46,14 +64,47 @@ func init() {
 	searchVerb.Add(stashSearch)
 }
 
-type stashUploader struct{}
+type stashUploader struct {
+	store stash.Store
+}
+
+func (u *stashUploader) prepare(ctx log.Context, conn *grpc.ClientConn) error {
+	store, err := newStashStore(ctx, conn)
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed connecting to stash")
+	}
+	u.store = store
+	return nil
+}
+
+func (u *stashUploader) process(ctx log.Context, filename string) error {
+	id, err := u.store.UploadFile(ctx, filename)
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed uploading to stash").With("filename", filename)
+	}
+	ctx.Raw("").Logf("Uploaded %s as %s", filename, id)
+	return nil
+}
 
-func (stashUploader) prepare(log.Context, *grpc.ClientConn) error { return nil }
-func (stashUploader) process(log.Context, string) error           { return nil }
+// newStashStore connects to the stash, preferring the S3-compatible bucket
+// named by -s3-bucket when set and falling back to the server's own store
+// over gRPC otherwise.
+func newStashStore(ctx log.Context, conn *grpc.ClientConn) (stash.Store, error) {
+	if s3Flags.bucket != "" {
+		return s3store.New(ctx, s3store.Config{
+			Endpoint:  s3Flags.endpoint,
+			Bucket:    s3Flags.bucket,
+			Region:    s3Flags.region,
+			PathStyle: s3Flags.pathStyle,
+			ACL:       s3Flags.acl,
+		})
+	}
+	return stashgrpc.Connect(ctx, conn)
+}
 
 func doStashSearch(ctx log.Context, flags flag.FlagSet) error {
 	return grpcutil.Client(ctx, serverAddress, func(ctx log.Context, conn *grpc.ClientConn) error {
-		store, err := stashgrpc.Connect(ctx, conn)
+		store, err := newStashStore(ctx, conn)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,120 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stash
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"debug/elf"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CanonicalHash returns the content id filename should be stored under. For
+// most files this is simply HashFile's hash of the raw bytes, but for
+// zip-based archives (APKs, .zip, .jar) and ELF shared objects it hashes a
+// canonical form with the entry mtimes and ELF section ordering that a
+// build's timestamp can perturb stripped out first. That way two otherwise
+// identical builds produced by different machines at different times still
+// collide into the same content id, rather than producing unrelated build
+// sets.
+func CanonicalHash(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".apk", ".zip", ".jar":
+		return canonicalZipHash(filename)
+	case ".so":
+		return canonicalELFHash(filename)
+	default:
+		f, err := os.Open(filename)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+}
+
+// canonicalZipHash hashes a zip archive's entry names and uncompressed
+// content, sorted by name, deliberately leaving out each entry's mtime and
+// every other piece of per-entry metadata that doesn't affect behaviour.
+func canonicalZipHash(filename string) (string, error) {
+	r, err := zip.OpenReader(filename)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	byName := make(map[string]*zip.File, len(r.File))
+	names := make([]string, 0, len(r.File))
+	for _, f := range r.File {
+		byName[f.Name] = f
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		io.WriteString(h, name)
+		rc, err := byName[name].Open()
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, rc)
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// canonicalELFHash hashes the names and contents of every ELF section that
+// occupies space in the file (all but SHT_NOBITS sections), sorted by name,
+// so that build-timestamp-sensitive metadata
+// outside of that content (such as section ordering or header padding)
+// can't change the hash.
+func canonicalELFHash(filename string) (string, error) {
+	f, err := elf.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	byName := map[string][]byte{}
+	names := make([]string, 0, len(f.Sections))
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_NOBITS {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return "", err
+		}
+		byName[s.Name] = data
+		names = append(names, s.Name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		io.WriteString(h, name)
+		h.Write(byName[name])
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
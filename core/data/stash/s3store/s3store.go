@@ -0,0 +1,169 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package s3store implements a stash.Store that keeps entity payloads in an
+// S3-compatible bucket (AWS S3, DigitalOcean Spaces, MinIO, ...) instead of
+// the robot server's local disk. The store talks to the bucket directly:
+// uploads, downloads and searches never pass through the server process,
+// and no entity metadata or object key is sent to the server's stash.
+package s3store
+
+import (
+	"io"
+	"os"
+	"path"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+
+	"github.com/google/gapid/core/data/search"
+	"github.com/google/gapid/core/data/stash"
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+)
+
+// Config holds the settings needed to address an S3-compatible bucket.
+// Credentials are not part of Config: they are resolved the normal AWS way,
+// from the environment or ~/.aws/credentials.
+type Config struct {
+	// Endpoint is the S3-compatible endpoint, empty for AWS S3 itself.
+	Endpoint string
+	// Bucket is the name of the bucket objects are stored under.
+	Bucket string
+	// Region is the bucket's region.
+	Region string
+	// PathStyle forces path-style addressing (endpoint/bucket/key),
+	// needed by most non-AWS S3-compatible services.
+	PathStyle bool
+	// ACL is the canned ACL applied to uploaded objects, for
+	// example "private" or "public-read". Empty keeps the bucket default.
+	ACL string
+}
+
+type store struct {
+	cfg        Config
+	client     *s3.S3
+	uploader   *s3manager.Uploader
+	downloader *s3manager.Downloader
+}
+
+// New returns a stash.Store backed by the bucket described by cfg.
+func New(ctx log.Context, cfg Config) (stash.Store, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+		Config: aws.Config{
+			Endpoint:         aws.String(cfg.Endpoint),
+			Region:           aws.String(cfg.Region),
+			S3ForcePathStyle: aws.Bool(cfg.PathStyle),
+		},
+	})
+	if err != nil {
+		return nil, cause.Explain(ctx, err, "Failed to create S3 session")
+	}
+	return &store{
+		cfg:        cfg,
+		client:     s3.New(sess),
+		uploader:   s3manager.NewUploader(sess),
+		downloader: s3manager.NewDownloader(sess),
+	}, nil
+}
+
+func (s *store) key(id string) string {
+	return path.Join("objects", id[:2], id)
+}
+
+// UploadFile streams filename directly into the bucket and returns the
+// content-addressed id the stash server should record for it.
+func (s *store) UploadFile(ctx log.Context, filename string) (string, error) {
+	// CanonicalHash, not HashFile, decides the id: for APKs and .so
+	// shared objects it hashes a form with build timestamps normalized
+	// out, so that two builds of the same CL by different builders at
+	// different times still land in the same build set.
+	id, err := stash.CanonicalHash(filename)
+	if err != nil {
+		return "", cause.Explain(ctx, err, "Failed to hash file")
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", cause.Explain(ctx, err, "Failed to open file for upload")
+	}
+	defer f.Close()
+	in := &s3manager.UploadInput{
+		Bucket: aws.String(s.cfg.Bucket),
+		Key:    aws.String(s.key(id)),
+		Body:   f,
+	}
+	if s.cfg.ACL != "" {
+		in.ACL = aws.String(s.cfg.ACL)
+	}
+	if _, err := s.uploader.UploadWithContext(ctx, in); err != nil {
+		return "", cause.Explain(ctx, err, "Failed to upload to S3")
+	}
+	return id, nil
+}
+
+// Open implements stash.Store by streaming the object stored under id
+// directly out of the bucket, so that callers such as registry.Push never
+// have to round-trip the bytes through the robot server.
+func (s *store) Open(ctx log.Context, id string) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := s.downloader.DownloadWithContext(ctx, fakeWriterAt{pw}, &s3.GetObjectInput{
+			Bucket: aws.String(s.cfg.Bucket),
+			Key:    aws.String(s.key(id)),
+		}, func(d *s3manager.Downloader) {
+			// A pipe can't be written to out of order, so force the parts
+			// to download and arrive sequentially.
+			d.Concurrency = 1
+		})
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+// fakeWriterAt adapts an io.Writer to the io.WriterAt the s3manager
+// downloader requires. With Concurrency forced to 1 above, the downloader
+// only ever writes sequential, non-overlapping ranges, so ignoring offset
+// is safe.
+type fakeWriterAt struct {
+	w io.Writer
+}
+
+func (f fakeWriterAt) WriteAt(p []byte, offset int64) (int, error) {
+	return f.w.Write(p)
+}
+
+// Search implements stash.Store by listing the bucket and reporting every
+// object whose derived entity matches query.
+func (s *store) Search(ctx log.Context, query *search.Query, handler stash.Handler) error {
+	return s.client.ListObjectsPagesWithContext(ctx, &s3.ListObjectsInput{
+		Bucket: aws.String(s.cfg.Bucket),
+		Prefix: aws.String("objects/"),
+	}, func(page *s3.ListObjectsOutput, last bool) bool {
+		for _, obj := range page.Contents {
+			entity := &stash.Entity{
+				Id:   path.Base(aws.StringValue(obj.Key)),
+				Size: aws.Int64Value(obj.Size),
+			}
+			if query.Accept(entity) {
+				if err := handler(ctx, entity); err != nil {
+					return false
+				}
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,61 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package stash implements the content-addressable blob store the robot
+// farm uses to move build artifacts and captures around without re-sending
+// bytes it already has.
+package stash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+
+	"github.com/google/gapid/core/data/search"
+	"github.com/google/gapid/core/log"
+)
+
+// Entity is a single object held by the stash.
+type Entity struct {
+	Id   string
+	Size int64
+}
+
+func (e *Entity) Reset()         { *e = Entity{} }
+func (e *Entity) String() string { return e.Id }
+func (e *Entity) ProtoMessage()  {}
+
+// Handler is called once per Entity that matches a Search query.
+type Handler func(ctx log.Context, entity *Entity) error
+
+// Store is the interface any stash backend must implement, whether it
+// keeps bytes on local disk, in an S3-compatible bucket, or elsewhere.
+type Store interface {
+	// Search reports every entity matching query to handler.
+	Search(ctx log.Context, query *search.Query, handler Handler) error
+	// UploadFile hashes and stores filename, returning its content id.
+	UploadFile(ctx log.Context, filename string) (string, error)
+	// Open returns a reader for the bytes stored under id.
+	Open(ctx log.Context, id string) (io.ReadCloser, error)
+}
+
+// HashFile returns the content id of the bytes read from r: the hex-encoded
+// SHA-256 of its contents.
+func HashFile(ctx log.Context, r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,118 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package registry pushes and lists GAPID robot build packages as OCI
+// artifacts, so that container-native infrastructure (Harbor, GHCR, ECR, ...)
+// can mirror and garbage-collect them alongside everything else it already
+// hosts.
+package registry
+
+import (
+	"encoding/base64"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+
+	"github.com/google/gapid/core/data/stash"
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/test/robot/build"
+)
+
+// Layer wraps the bytes of the stash entity named id as an OCI layer blob
+// (gzip-compressed unless they already are). The layer reopens the entity
+// through store whenever its contents are read, so it can be pushed more
+// than once (e.g. when a build set's manifest is re-pushed as later
+// artifacts arrive).
+func Layer(ctx log.Context, store stash.Store, id string) (v1.Layer, error) {
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return store.Open(ctx, id)
+	})
+	if err != nil {
+		return nil, cause.Explain(ctx, err, "Failed to create layer from stash entity").With("id", id)
+	}
+	return layer, nil
+}
+
+// Push assembles layers into a single OCI artifact and pushes it to ref,
+// with info serialized into the config blob so that the package's
+// provenance survives a `crane pull` done outside of the robot server.
+// layers should be every artifact in the build set ref names - callers that
+// learn artifacts one at a time should call Push again each time with the
+// full set of layers collected so far (see Layer, which makes a layer safe
+// to push more than once), since the push is idempotent and the final call
+// leaves the registry with a complete manifest.
+func Push(ctx log.Context, ref string, info *build.Information, layers []v1.Layer) error {
+	tag, err := name.ParseReference(ref)
+	if err != nil {
+		return cause.Explain(ctx, err, "Malformed registry reference")
+	}
+	cfg, err := proto.Marshal(info)
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed to marshal build information")
+	}
+	img, err := mutate.ConfigFile(empty.Image, &v1.ConfigFile{})
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed to create image config")
+	}
+	img = mutate.Annotations(img, map[string]string{
+		"gapid.build.cl":     info.Cl,
+		"gapid.build.branch": info.Branch,
+		"gapid.build.tag":    info.Tag,
+	}).(v1.Image)
+	if img, err = mutate.AppendLayers(img, layers...); err != nil {
+		return cause.Explain(ctx, err, "Failed to append layers")
+	}
+	// info is protobuf-marshaled, so it's arbitrary binary rather than
+	// valid UTF-8 - base64 it before storing it in a JSON-serialized label,
+	// or json's encoder would silently corrupt it by replacing invalid byte
+	// sequences with U+FFFD.
+	encoded := base64.StdEncoding.EncodeToString(cfg)
+	img, err = mutate.ConfigFile(img, &v1.ConfigFile{Config: v1.Config{Labels: map[string]string{"gapid.build.info": encoded}}})
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed to embed build information")
+	}
+	if err := crane.Push(img, tag.String()); err != nil {
+		return cause.Explain(ctx, err, "Failed to push to registry")
+	}
+	return nil
+}
+
+// List returns the tags present at ref, one per matching build.Package.
+func List(ctx log.Context, ref string) ([]string, error) {
+	repo, err := name.NewRepository(ref)
+	if err != nil {
+		return nil, cause.Explain(ctx, err, "Malformed registry reference")
+	}
+	tags, err := crane.ListTags(repo.String())
+	if err != nil {
+		return nil, cause.Explain(ctx, err, "Failed to list registry tags")
+	}
+	return tags, nil
+}
+
+// Manifest fetches and returns the raw manifest for ref.
+func Manifest(ctx log.Context, ref string) ([]byte, error) {
+	m, err := crane.Manifest(ref)
+	if err != nil {
+		return nil, cause.Explain(ctx, err, "Failed to fetch registry manifest")
+	}
+	return m, nil
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit emits a structured event for every build upload and track
+// mutation, so that operators can trace who changed what, from where, and
+// when, without having to recompile the robot server to add a new sink.
+package audit
+
+import (
+	"fmt"
+
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/test/robot/build"
+)
+
+// Event describes a single auditable action against the build store.
+type Event struct {
+	// Action is a short name for what happened, e.g. "store.Add",
+	// "UpdateTrack", or "stash.Upload".
+	Action string
+	// Who performed the action, usually build.Information.Uploader.
+	Who string
+	// When the action was recorded, as an RFC3339 timestamp.
+	When string
+	// From is the remote address or host the action came from.
+	From string
+	// Cl is the CL the action is associated with, if any.
+	Cl string
+	// Id is the resulting id, e.g. a build set or track id.
+	Id string
+	// Merged reports whether an upload merged with an existing build
+	// set rather than creating a new one.
+	Merged bool
+	// Type is the detected build.Type of the upload, present so that
+	// tampered Local uploads stand out in review.
+	Type build.Type
+	// Before and After capture track state for UpdateTrack events.
+	Before *build.Track `json:",omitempty"`
+	After  *build.Track `json:",omitempty"`
+}
+
+// Sink receives audit events. Implementations must be safe to call from
+// multiple goroutines.
+type Sink interface {
+	Write(ctx log.Context, event *Event) error
+}
+
+// Constructor builds a Sink from its configuration string. What config means
+// is specific to each registered sink kind.
+type Constructor func(ctx log.Context, config string) (Sink, error)
+
+var registry = map[string]Constructor{}
+
+// Register adds a sink kind under name, so that New(name, ...) can find it.
+// Called from the init() of each sink implementation.
+func Register(name string, ctor Constructor) {
+	registry[name] = ctor
+}
+
+// New constructs the sink registered under name, passing it config.
+func New(ctx log.Context, name, config string) (Sink, error) {
+	ctor, ok := registry[name]
+	if !ok {
+		return nil, cause.Explain(ctx, nil, "Unknown audit sink").With("name", name)
+	}
+	return ctor(ctx, config)
+}
+
+// Multi fans a single Write out to every sink in sinks, returning the first
+// error encountered after attempting all of them.
+type Multi []Sink
+
+func (m Multi) Write(ctx log.Context, event *Event) error {
+	var first error
+	for _, sink := range m {
+		if err := sink.Write(ctx, event); err != nil && first == nil {
+			first = fmt.Errorf("%s: %v", "audit sink failed", err)
+		}
+	}
+	return first
+}
@@ -0,0 +1,57 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package file implements an audit.Sink that appends one JSON object per
+// line to a local file, the simplest sink an operator can point at a log
+// shipper without running anything extra.
+package file
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/test/robot/build/audit"
+)
+
+func init() {
+	audit.Register("file", New)
+}
+
+type sink struct {
+	mu sync.Mutex
+	f  *os.File
+	e  *json.Encoder
+}
+
+// New opens path (creating it if needed) and returns an audit.Sink that
+// appends JSONL events to it. config is the path to the file.
+func New(ctx log.Context, config string) (audit.Sink, error) {
+	f, err := os.OpenFile(config, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, cause.Explain(ctx, err, "Failed to open audit log file").With("path", config)
+	}
+	return &sink{f: f, e: json.NewEncoder(f)}, nil
+}
+
+func (s *sink) Write(ctx log.Context, event *audit.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.e.Encode(event); err != nil {
+		return cause.Explain(ctx, err, "Failed to write audit event")
+	}
+	return nil
+}
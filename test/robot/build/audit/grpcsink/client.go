@@ -0,0 +1,60 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcsink
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+)
+
+// ForwardRequest mirrors the message declared in audit.proto.
+type ForwardRequest struct {
+	Event []byte `protobuf:"bytes,1,opt,name=event,proto3"`
+}
+
+func (m *ForwardRequest) Reset()         { *m = ForwardRequest{} }
+func (m *ForwardRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (m *ForwardRequest) ProtoMessage()  {}
+
+// ForwardResponse mirrors the message declared in audit.proto.
+type ForwardResponse struct{}
+
+func (m *ForwardResponse) Reset()         { *m = ForwardResponse{} }
+func (m *ForwardResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (m *ForwardResponse) ProtoMessage()  {}
+
+// ForwarderClient is the client API for the Forwarder service.
+type ForwarderClient interface {
+	Forward(ctx context.Context, in *ForwardRequest) (*ForwardResponse, error)
+}
+
+type forwarderClient struct {
+	conn *grpc.ClientConn
+}
+
+// NewForwarderClient returns a client for the Forwarder service over conn.
+func NewForwarderClient(conn *grpc.ClientConn) ForwarderClient {
+	return &forwarderClient{conn: conn}
+}
+
+func (c *forwarderClient) Forward(ctx context.Context, in *ForwardRequest) (*ForwardResponse, error) {
+	out := new(ForwardResponse)
+	if err := c.conn.Invoke(ctx, "/build.audit.Forwarder/Forward", in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,54 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grpcsink implements an audit.Sink that forwards events to another
+// robot component over gRPC, for example a central SIEM forwarder that
+// several robot servers share.
+package grpcsink
+
+import (
+	"encoding/json"
+
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/net/grpcutil"
+	"github.com/google/gapid/test/robot/build/audit"
+	"google.golang.org/grpc"
+)
+
+func init() {
+	audit.Register("grpc", New)
+}
+
+type sink struct {
+	address string
+}
+
+// New returns an audit.Sink that forwards each event, JSON-encoded, to the
+// audit forwarder listening at config (a host:port address).
+func New(ctx log.Context, config string) (audit.Sink, error) {
+	return &sink{address: config}, nil
+}
+
+func (s *sink) Write(ctx log.Context, event *audit.Event) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed to marshal audit event")
+	}
+	return grpcutil.Client(ctx, s.address, func(ctx log.Context, conn *grpc.ClientConn) error {
+		client := NewForwarderClient(conn)
+		_, err := client.Forward(ctx, &ForwardRequest{Event: b})
+		return err
+	}, grpc.WithInsecure())
+}
@@ -0,0 +1,88 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcsink
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/test/robot/build/audit"
+)
+
+// ForwarderServer is the server API for the Forwarder service.
+type ForwarderServer interface {
+	Forward(ctx context.Context, in *ForwardRequest) (*ForwardResponse, error)
+}
+
+// forwarder implements ForwarderServer by decoding each forwarded event and
+// writing it into an underlying audit.Sink, so that a central component can
+// receive events relayed by grpcsink.New from elsewhere.
+type forwarder struct {
+	ctx  log.Context
+	sink audit.Sink
+}
+
+// NewForwarder returns a ForwarderServer that decodes every forwarded event
+// and writes it to sink.
+func NewForwarder(ctx log.Context, sink audit.Sink) ForwarderServer {
+	return &forwarder{ctx: ctx, sink: sink}
+}
+
+func (f *forwarder) Forward(ctx context.Context, in *ForwardRequest) (*ForwardResponse, error) {
+	event := &audit.Event{}
+	if err := json.Unmarshal(in.Event, event); err != nil {
+		return nil, cause.Explain(f.ctx, err, "Failed to decode forwarded audit event")
+	}
+	if err := f.sink.Write(f.ctx, event); err != nil {
+		return nil, err
+	}
+	return &ForwardResponse{}, nil
+}
+
+// RegisterForwarderServer registers srv as the handler for the Forwarder
+// service on s, the hand-rolled equivalent of what protoc-gen-go-grpc would
+// generate from audit.proto.
+func RegisterForwarderServer(s *grpc.Server, srv ForwarderServer) {
+	s.RegisterService(&forwarderServiceDesc, srv)
+}
+
+var forwarderServiceDesc = grpc.ServiceDesc{
+	ServiceName: "build.audit.Forwarder",
+	HandlerType: (*ForwarderServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Forward",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(ForwardRequest)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(ForwarderServer).Forward(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/build.audit.Forwarder/Forward"}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(ForwarderServer).Forward(ctx, req.(*ForwardRequest))
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
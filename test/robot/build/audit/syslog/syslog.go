@@ -0,0 +1,64 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package syslog implements an audit.Sink that forwards events to the local
+// or remote syslog daemon, for operators who already centralize logs that
+// way.
+package syslog
+
+import (
+	"encoding/json"
+	slog "log/syslog"
+
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/test/robot/build/audit"
+)
+
+func init() {
+	audit.Register("syslog", New)
+}
+
+type sink struct {
+	w *slog.Writer
+}
+
+// New dials the syslog daemon and returns an audit.Sink that forwards to it.
+// config is the network address to dial, or empty for the local daemon.
+func New(ctx log.Context, config string) (audit.Sink, error) {
+	var (
+		w   *slog.Writer
+		err error
+	)
+	if config == "" {
+		w, err = slog.New(slog.LOG_INFO|slog.LOG_USER, "gapid-robot")
+	} else {
+		w, err = slog.Dial("tcp", config, slog.LOG_INFO|slog.LOG_USER, "gapid-robot")
+	}
+	if err != nil {
+		return nil, cause.Explain(ctx, err, "Failed to connect to syslog")
+	}
+	return &sink{w: w}, nil
+}
+
+func (s *sink) Write(ctx log.Context, event *audit.Event) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed to marshal audit event")
+	}
+	if err := s.w.Info(string(b)); err != nil {
+		return cause.Explain(ctx, err, "Failed to write to syslog")
+	}
+	return nil
+}
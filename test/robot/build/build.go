@@ -0,0 +1,292 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package build holds the robot server's model of uploaded builds: the
+// artifacts that make up a build set, the packages they are grouped into,
+// the tracks that follow a moving head package, and the webhooks that get
+// notified when any of that changes.
+package build
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/google/gapid/core/data/search"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"google.golang.org/grpc"
+)
+
+// Type describes how an upload's provenance was established.
+type Type int
+
+const (
+	// BuildBot is an upload from a CI or bot build: one made under a
+	// detected CI provider, or from a directory git cannot open.
+	BuildBot Type = iota
+	// User is an upload built from a clean checkout by a human.
+	User
+	// Local is an upload built from a checkout with local modifications.
+	Local
+)
+
+func (t Type) String() string {
+	switch t {
+	case BuildBot:
+		return "BuildBot"
+	case User:
+		return "User"
+	case Local:
+		return "Local"
+	default:
+		return fmt.Sprintf("Type(%d)", int(t))
+	}
+}
+
+// Information describes a single upload: who built it, from what, and when.
+type Information struct {
+	Type        Type
+	Branch      string
+	Cl          string
+	Tag         string
+	Description string
+	Builder     *device.Instance
+	Uploader    string
+	Timestamp   *timestamp.Timestamp
+	// CiProvider is the short name of the hosted CI system that produced
+	// this upload, e.g. "github", empty if none was detected.
+	CiProvider string
+	// CiBuildUrl links back to the CI run that produced this upload, when
+	// the provider exposes one.
+	CiBuildUrl string
+}
+
+func (i *Information) Reset()         { *i = Information{} }
+func (i *Information) String() string { return fmt.Sprintf("%+v", *i) }
+func (i *Information) ProtoMessage()  {}
+
+// Artifact is a single uploaded file, recorded against the build set it
+// was merged into.
+type Artifact struct {
+	Id    string
+	Name  string
+	Info  *Information
+	Stash string
+}
+
+func (a *Artifact) Reset()         { *a = Artifact{} }
+func (a *Artifact) String() string { return fmt.Sprintf("%+v", *a) }
+func (a *Artifact) ProtoMessage()  {}
+
+// Package is a build set: every Information that merged together, plus the
+// stash ids of the files that make it up.
+type Package struct {
+	Id          string
+	Info        *Information
+	ArtifactIds []string
+}
+
+func (p *Package) Reset()         { *p = Package{} }
+func (p *Package) String() string { return fmt.Sprintf("%+v", *p) }
+func (p *Package) ProtoMessage()  {}
+
+// Track is a named, moving pointer at the head Package of some release
+// channel, e.g. "canary" or "stable".
+type Track struct {
+	Id          string
+	Name        string
+	Description string
+	Head        string
+}
+
+func (t *Track) Reset()         { *t = Track{} }
+func (t *Track) String() string { return fmt.Sprintf("%+v", *t) }
+func (t *Track) ProtoMessage()  {}
+
+// Webhook is a registered delivery target notified whenever a new build set
+// appears or a track's head moves.
+type Webhook struct {
+	Id     string
+	Name   string
+	Url    string
+	Format string
+	Secret string
+}
+
+func (w *Webhook) Reset()         { *w = Webhook{} }
+func (w *Webhook) String() string { return fmt.Sprintf("%+v", *w) }
+func (w *Webhook) ProtoMessage()  {}
+
+// ArtifactHandler is called once per Artifact that matches a search.
+type ArtifactHandler func(ctx log.Context, entry *Artifact) error
+
+// PackageHandler is called once per Package that matches a search.
+type PackageHandler func(ctx log.Context, entry *Package) error
+
+// TrackHandler is called once per Track that matches a search.
+type TrackHandler func(ctx log.Context, entry *Track) error
+
+// WebhookHandler is called once per Webhook that matches a search.
+type WebhookHandler func(ctx log.Context, entry *Webhook) error
+
+// Store is the interface the robot server exposes for recording and
+// querying builds.
+type Store interface {
+	// Add records that id (a stash entity) was uploaded with info,
+	// merging it into an existing build set when one already matches,
+	// and returns the resulting build set id plus whether it merged.
+	Add(ctx log.Context, id string, info *Information) (string, bool, error)
+	SearchArtifacts(ctx log.Context, query *search.Query, handler ArtifactHandler) error
+	SearchPackages(ctx log.Context, query *search.Query, handler PackageHandler) error
+	SearchTracks(ctx log.Context, query *search.Query, handler TrackHandler) error
+	UpdateTrack(ctx log.Context, track *Track) (*Track, error)
+	SearchWebhooks(ctx log.Context, query *search.Query, handler WebhookHandler) error
+	UpdateWebhook(ctx log.Context, webhook *Webhook) (*Webhook, error)
+}
+
+// NewRemote returns a Store that talks to the robot server's build service
+// over conn.
+func NewRemote(ctx log.Context, conn *grpc.ClientConn) Store {
+	return &remote{conn: conn}
+}
+
+type remote struct {
+	conn *grpc.ClientConn
+}
+
+func (r *remote) Add(ctx log.Context, id string, info *Information) (string, bool, error) {
+	out := &addResponse{}
+	if err := r.conn.Invoke(ctx, "/build.Build/Add", &addRequest{Id: id, Info: info}, out); err != nil {
+		return "", false, err
+	}
+	return out.Id, out.Merged, nil
+}
+
+func (r *remote) SearchArtifacts(ctx log.Context, query *search.Query, handler ArtifactHandler) error {
+	out := &artifactSearchResponse{}
+	if err := r.conn.Invoke(ctx, "/build.Build/SearchArtifacts", &searchRequest{Query: query}, out); err != nil {
+		return err
+	}
+	for _, entry := range out.Entries {
+		if err := handler(ctx, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *remote) SearchPackages(ctx log.Context, query *search.Query, handler PackageHandler) error {
+	out := &packageSearchResponse{}
+	if err := r.conn.Invoke(ctx, "/build.Build/SearchPackages", &searchRequest{Query: query}, out); err != nil {
+		return err
+	}
+	for _, entry := range out.Entries {
+		if err := handler(ctx, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *remote) SearchTracks(ctx log.Context, query *search.Query, handler TrackHandler) error {
+	out := &trackSearchResponse{}
+	if err := r.conn.Invoke(ctx, "/build.Build/SearchTracks", &searchRequest{Query: query}, out); err != nil {
+		return err
+	}
+	for _, entry := range out.Entries {
+		if err := handler(ctx, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *remote) UpdateTrack(ctx log.Context, track *Track) (*Track, error) {
+	out := &Track{}
+	if err := r.conn.Invoke(ctx, "/build.Build/UpdateTrack", track, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *remote) SearchWebhooks(ctx log.Context, query *search.Query, handler WebhookHandler) error {
+	out := &webhookSearchResponse{}
+	if err := r.conn.Invoke(ctx, "/build.Build/SearchWebhooks", &searchRequest{Query: query}, out); err != nil {
+		return err
+	}
+	for _, entry := range out.Entries {
+		if err := handler(ctx, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *remote) UpdateWebhook(ctx log.Context, webhook *Webhook) (*Webhook, error) {
+	out := &Webhook{}
+	if err := r.conn.Invoke(ctx, "/build.Build/UpdateWebhook", webhook, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type addRequest struct {
+	Id   string
+	Info *Information
+}
+
+func (r *addRequest) Reset()         { *r = addRequest{} }
+func (r *addRequest) String() string { return fmt.Sprintf("%+v", *r) }
+func (r *addRequest) ProtoMessage()  {}
+
+type addResponse struct {
+	Id     string
+	Merged bool
+}
+
+func (r *addResponse) Reset()         { *r = addResponse{} }
+func (r *addResponse) String() string { return fmt.Sprintf("%+v", *r) }
+func (r *addResponse) ProtoMessage()  {}
+
+type searchRequest struct {
+	Query *search.Query
+}
+
+func (r *searchRequest) Reset()         { *r = searchRequest{} }
+func (r *searchRequest) String() string { return fmt.Sprintf("%+v", *r) }
+func (r *searchRequest) ProtoMessage()  {}
+
+type artifactSearchResponse struct{ Entries []*Artifact }
+
+func (r *artifactSearchResponse) Reset()         { *r = artifactSearchResponse{} }
+func (r *artifactSearchResponse) String() string { return fmt.Sprintf("%+v", *r) }
+func (r *artifactSearchResponse) ProtoMessage()  {}
+
+type packageSearchResponse struct{ Entries []*Package }
+
+func (r *packageSearchResponse) Reset()         { *r = packageSearchResponse{} }
+func (r *packageSearchResponse) String() string { return fmt.Sprintf("%+v", *r) }
+func (r *packageSearchResponse) ProtoMessage()  {}
+
+type trackSearchResponse struct{ Entries []*Track }
+
+func (r *trackSearchResponse) Reset()         { *r = trackSearchResponse{} }
+func (r *trackSearchResponse) String() string { return fmt.Sprintf("%+v", *r) }
+func (r *trackSearchResponse) ProtoMessage()  {}
+
+type webhookSearchResponse struct{ Entries []*Webhook }
+
+func (r *webhookSearchResponse) Reset()         { *r = webhookSearchResponse{} }
+func (r *webhookSearchResponse) String() string { return fmt.Sprintf("%+v", *r) }
+func (r *webhookSearchResponse) ProtoMessage()  {}
@@ -0,0 +1,114 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ci detects which, if any, hosted CI system a build upload is
+// running under, so that buildUploader.prepare can prefer its environment
+// variables over shelling out to git in what may be a shallow checkout.
+package ci
+
+import "os"
+
+// Info is what was detected about the CI environment the upload is running
+// in.
+type Info struct {
+	// Provider is a short name for the detected CI system, e.g. "github".
+	Provider string
+	// Commit is the SHA of the commit being built.
+	Commit string
+	// Branch is the branch or ref being built.
+	Branch string
+	// Actor is the user or account that triggered the build.
+	Actor string
+	// BuildURL links back to the CI run, when the provider exposes one.
+	BuildURL string
+}
+
+// detectors are tried in order; the first one whose required environment
+// variable is set wins.
+var detectors = []func() (*Info, bool){
+	detectGitHubActions,
+	detectDrone,
+	detectGitLabCI,
+	detectJenkins,
+}
+
+// Detect returns the first CI environment recognised from the process
+// environment, or false if none was.
+func Detect() (*Info, bool) {
+	for _, detect := range detectors {
+		if info, ok := detect(); ok {
+			return info, true
+		}
+	}
+	return nil, false
+}
+
+func detectGitHubActions() (*Info, bool) {
+	sha := os.Getenv("GITHUB_SHA")
+	if sha == "" {
+		return nil, false
+	}
+	runID := os.Getenv("GITHUB_RUN_ID")
+	url := ""
+	if repo := os.Getenv("GITHUB_REPOSITORY"); repo != "" && runID != "" {
+		url = "https://github.com/" + repo + "/actions/runs/" + runID
+	}
+	return &Info{
+		Provider: "github",
+		Commit:   sha,
+		Branch:   os.Getenv("GITHUB_REF"),
+		Actor:    os.Getenv("GITHUB_ACTOR"),
+		BuildURL: url,
+	}, true
+}
+
+func detectDrone() (*Info, bool) {
+	commit := os.Getenv("DRONE_COMMIT")
+	if commit == "" {
+		return nil, false
+	}
+	return &Info{
+		Provider: "drone",
+		Commit:   commit,
+		Branch:   os.Getenv("DRONE_BRANCH"),
+		BuildURL: os.Getenv("DRONE_BUILD_LINK"),
+	}, true
+}
+
+func detectGitLabCI() (*Info, bool) {
+	commit := os.Getenv("CI_COMMIT_SHA")
+	if commit == "" {
+		return nil, false
+	}
+	return &Info{
+		Provider: "gitlab",
+		Commit:   commit,
+		Branch:   os.Getenv("CI_COMMIT_REF_NAME"),
+		Actor:    os.Getenv("GITLAB_USER_LOGIN"),
+		BuildURL: os.Getenv("CI_JOB_URL"),
+	}, true
+}
+
+func detectJenkins() (*Info, bool) {
+	commit := os.Getenv("GIT_COMMIT")
+	if commit == "" {
+		return nil, false
+	}
+	return &Info{
+		Provider: "jenkins",
+		Commit:   commit,
+		Branch:   os.Getenv("BRANCH_NAME"),
+		BuildURL: os.Getenv("BUILD_URL"),
+	}, true
+}
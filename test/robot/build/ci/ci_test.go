@@ -0,0 +1,141 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ci
+
+import (
+	"os"
+	"testing"
+)
+
+func clearEnv(t *testing.T, names ...string) {
+	for _, name := range names {
+		old, had := os.LookupEnv(name)
+		os.Unsetenv(name)
+		if had {
+			t.Cleanup(func() { os.Setenv(name, old) })
+		}
+	}
+}
+
+func setEnv(t *testing.T, name, value string) {
+	old, had := os.LookupEnv(name)
+	os.Setenv(name, value)
+	t.Cleanup(func() {
+		if had {
+			os.Setenv(name, old)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+var allEnvVars = []string{
+	"GITHUB_SHA", "GITHUB_REF", "GITHUB_ACTOR", "GITHUB_REPOSITORY", "GITHUB_RUN_ID",
+	"DRONE_COMMIT", "DRONE_BRANCH", "DRONE_BUILD_LINK",
+	"CI_COMMIT_SHA", "CI_COMMIT_REF_NAME", "GITLAB_USER_LOGIN", "CI_JOB_URL",
+	"GIT_COMMIT", "BRANCH_NAME", "BUILD_URL",
+}
+
+func TestDetectNone(t *testing.T) {
+	clearEnv(t, allEnvVars...)
+	if _, ok := Detect(); ok {
+		t.Fatal("Detect() succeeded with no CI environment variables set")
+	}
+}
+
+func TestDetectGitHubActions(t *testing.T) {
+	clearEnv(t, allEnvVars...)
+	setEnv(t, "GITHUB_SHA", "abc123")
+	setEnv(t, "GITHUB_REF", "refs/heads/main")
+	setEnv(t, "GITHUB_ACTOR", "octocat")
+	setEnv(t, "GITHUB_REPOSITORY", "google/gapid")
+	setEnv(t, "GITHUB_RUN_ID", "42")
+	info, ok := Detect()
+	if !ok {
+		t.Fatal("Detect() failed to recognise GitHub Actions")
+	}
+	want := &Info{
+		Provider: "github",
+		Commit:   "abc123",
+		Branch:   "refs/heads/main",
+		Actor:    "octocat",
+		BuildURL: "https://github.com/google/gapid/actions/runs/42",
+	}
+	if *info != *want {
+		t.Errorf("Detect() = %+v, want %+v", info, want)
+	}
+}
+
+func TestDetectDrone(t *testing.T) {
+	clearEnv(t, allEnvVars...)
+	setEnv(t, "DRONE_COMMIT", "abc123")
+	setEnv(t, "DRONE_BRANCH", "main")
+	setEnv(t, "DRONE_BUILD_LINK", "https://drone.example/build/1")
+	info, ok := Detect()
+	if !ok {
+		t.Fatal("Detect() failed to recognise Drone")
+	}
+	want := &Info{
+		Provider: "drone",
+		Commit:   "abc123",
+		Branch:   "main",
+		BuildURL: "https://drone.example/build/1",
+	}
+	if *info != *want {
+		t.Errorf("Detect() = %+v, want %+v", info, want)
+	}
+}
+
+func TestDetectGitLabCI(t *testing.T) {
+	clearEnv(t, allEnvVars...)
+	setEnv(t, "CI_COMMIT_SHA", "abc123")
+	setEnv(t, "CI_COMMIT_REF_NAME", "main")
+	setEnv(t, "GITLAB_USER_LOGIN", "alice")
+	setEnv(t, "CI_JOB_URL", "https://gitlab.example/job/1")
+	info, ok := Detect()
+	if !ok {
+		t.Fatal("Detect() failed to recognise GitLab CI")
+	}
+	want := &Info{
+		Provider: "gitlab",
+		Commit:   "abc123",
+		Branch:   "main",
+		Actor:    "alice",
+		BuildURL: "https://gitlab.example/job/1",
+	}
+	if *info != *want {
+		t.Errorf("Detect() = %+v, want %+v", info, want)
+	}
+}
+
+func TestDetectJenkins(t *testing.T) {
+	clearEnv(t, allEnvVars...)
+	setEnv(t, "GIT_COMMIT", "abc123")
+	setEnv(t, "BRANCH_NAME", "main")
+	setEnv(t, "BUILD_URL", "https://jenkins.example/job/1")
+	info, ok := Detect()
+	if !ok {
+		t.Fatal("Detect() failed to recognise Jenkins")
+	}
+	want := &Info{
+		Provider: "jenkins",
+		Commit:   "abc123",
+		Branch:   "main",
+		BuildURL: "https://jenkins.example/job/1",
+	}
+	if *info != *want {
+		t.Errorf("Detect() = %+v, want %+v", info, want)
+	}
+}
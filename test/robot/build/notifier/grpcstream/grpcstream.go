@@ -0,0 +1,67 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grpcstream implements the "grpc" notifier.Transport: rather than
+// dialing out, it fans each payload out, in process, to the channels
+// currently registered with the transport's Subscribe method. No gRPC
+// service exposes these payloads, and the transport value is only reachable
+// through notifier's registry, so Subscribe can only be called from within
+// this package.
+package grpcstream
+
+import (
+	"sync"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/test/robot/build"
+	"github.com/google/gapid/test/robot/build/notifier"
+)
+
+func init() {
+	notifier.Register("grpc", &transport{})
+}
+
+type transport struct {
+	mu   sync.Mutex
+	subs map[chan []byte]struct{}
+}
+
+// Subscribe registers a channel that receives every payload sent to a
+// webhook with Format "grpc", and returns a function that unregisters it.
+func (t *transport) Subscribe(c chan []byte) func() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.subs == nil {
+		t.subs = map[chan []byte]struct{}{}
+	}
+	t.subs[c] = struct{}{}
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.subs, c)
+	}
+}
+
+func (t *transport) Send(ctx log.Context, webhook *build.Webhook, payload []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for c := range t.subs {
+		select {
+		case c <- payload:
+		default:
+			// A slow subscriber shouldn't block or lose other deliveries.
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package http implements the generic "http" notifier.Transport: a plain
+// signed POST of the JSON payload.
+package http
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/test/robot/build"
+	"github.com/google/gapid/test/robot/build/notifier"
+)
+
+func init() {
+	notifier.Register("http", transport{})
+}
+
+type transport struct{}
+
+func (transport) Send(ctx log.Context, webhook *build.Webhook, payload []byte) error {
+	req, err := http.NewRequest("POST", webhook.Url, bytes.NewReader(payload))
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed to build webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if webhook.Secret != "" {
+		req.Header.Set("X-Gapid-Signature", notifier.Sign(webhook.Secret, payload))
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return cause.Explain(ctx, err, "Webhook request failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return cause.Explain(ctx, nil, "Webhook returned an error status").With("status", resp.StatusCode)
+	}
+	return nil
+}
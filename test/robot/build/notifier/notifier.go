@@ -0,0 +1,119 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notifier fires configurable webhooks whenever a new (non-merged)
+// build set appears or a track's head moves, so that other systems can react
+// without polling the robot server.
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/test/robot/build"
+)
+
+// Payload is the JSON body delivered to every webhook.
+type Payload struct {
+	// Event is "build" or "track".
+	Event string
+	// Info describes the build that triggered the event.
+	Info *build.Information `json:",omitempty"`
+	// ArtifactIds are the artifacts that make up the new build set.
+	ArtifactIds []string `json:",omitempty"`
+	// Track is the track whose head moved, for "track" events.
+	Track *build.Track `json:",omitempty"`
+	// PreviousHead is the track's head before this event, for diffing.
+	PreviousHead string `json:",omitempty"`
+	// Diff renders PreviousHead vs the track's new head, for "track" events.
+	Diff string `json:",omitempty"`
+}
+
+// Transport delivers a signed payload to a single webhook.
+type Transport interface {
+	Send(ctx log.Context, webhook *build.Webhook, payload []byte) error
+}
+
+// registry of transports, keyed by build.Webhook.Format.
+var registry = map[string]Transport{}
+
+// Register adds a transport under format, so that Notify can dispatch to it.
+func Register(format string, t Transport) {
+	registry[format] = t
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 of payload using secret, for
+// transports to set as an X-Gapid-Signature header.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff is the retry schedule used by Notify: each attempt waits longer
+// than the last, capping out rather than retrying forever.
+var Backoff = []time.Duration{
+	1 * time.Second,
+	5 * time.Second,
+	30 * time.Second,
+	2 * time.Minute,
+}
+
+// Notify encodes payload and delivers it to webhook via the transport
+// registered for its Format, retrying on failure with Backoff.
+func Notify(ctx log.Context, webhook *build.Webhook, payload *Payload) error {
+	transport, ok := registry[webhook.Format]
+	if !ok {
+		return cause.Explain(ctx, nil, "Unknown webhook format").With("format", webhook.Format)
+	}
+	if payload.Track != nil {
+		payload.Diff = diff(payload.PreviousHead, payload.Track.Head)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed to marshal webhook payload")
+	}
+	var last error
+	for attempt := 0; ; attempt++ {
+		if last = transport.Send(ctx, webhook, body); last == nil {
+			return nil
+		}
+		if attempt >= len(Backoff) {
+			return cause.Explain(ctx, last, "Webhook delivery failed").With("webhook", webhook.Id)
+		}
+		select {
+		case <-time.After(Backoff[attempt]):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// diff renders before vs after in a human-readable form, for Payload.Diff.
+func diff(before, after string) string {
+	if before == after {
+		return ""
+	}
+	var b bytes.Buffer
+	b.WriteString(before)
+	b.WriteString(" -> ")
+	b.WriteString(after)
+	return b.String()
+}
@@ -0,0 +1,70 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package slack implements the "slack" notifier.Transport: it reformats the
+// raw JSON payload as a Slack incoming-webhook message before posting it.
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/test/robot/build"
+	"github.com/google/gapid/test/robot/build/notifier"
+)
+
+func init() {
+	notifier.Register("slack", transport{})
+}
+
+type transport struct{}
+
+type message struct {
+	Text string `json:"text"`
+}
+
+func (transport) Send(ctx log.Context, webhook *build.Webhook, payload []byte) error {
+	var decoded notifier.Payload
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		return cause.Explain(ctx, err, "Failed to decode webhook payload")
+	}
+	text := formatText(&decoded)
+	body, err := json.Marshal(message{Text: text})
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed to marshal Slack message")
+	}
+	resp, err := http.Post(webhook.Url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return cause.Explain(ctx, err, "Slack webhook request failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return cause.Explain(ctx, nil, "Slack webhook returned an error status").With("status", resp.StatusCode)
+	}
+	return nil
+}
+
+func formatText(p *notifier.Payload) string {
+	switch p.Event {
+	case "build":
+		return "New build set for CL " + p.Info.Cl + " on branch " + p.Info.Branch
+	case "track":
+		return "Track " + p.Track.Name + " head moved: " + p.Diff
+	default:
+		return "Unknown gapid robot event"
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sourcedate resolves the -source-date upload flag into the mode
+// that picks the timestamp embedded in a build.Information, so that two
+// independent builders of the same CL can record the same timestamp. The
+// uploaded artifacts' own bytes and file metadata are not rewritten with it.
+package sourcedate
+
+import (
+	"time"
+
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+)
+
+// Mode selects how the upload timestamp is derived.
+type Mode int
+
+const (
+	// Zero embeds the Unix epoch, for maximally reproducible builds that
+	// don't care when they happened.
+	Zero Mode = iota
+	// SourceTimestamp uses the commit timestamp of the CL being built.
+	SourceTimestamp
+	// BuildTimestamp uses the time the upload is run.
+	BuildTimestamp
+	// Explicit uses a caller-supplied RFC3339 timestamp.
+	Explicit
+)
+
+// Resolve parses value (one of "Zero", "SourceTimestamp", "BuildTimestamp", or
+// an RFC3339 timestamp) and returns the mode and, for Explicit, the parsed
+// time. Unknown values are rejected rather than silently ignored, since a
+// typo here would otherwise make builds non-reproducible in a way that's
+// hard to notice.
+func Resolve(ctx log.Context, value string) (Mode, time.Time, error) {
+	switch value {
+	case "", "Zero":
+		return Zero, time.Unix(0, 0).UTC(), nil
+	case "SourceTimestamp":
+		return SourceTimestamp, time.Time{}, nil
+	case "BuildTimestamp":
+		return BuildTimestamp, time.Time{}, nil
+	default:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return Zero, time.Time{}, cause.Explain(ctx, nil, "OutputTimestampValueNotSupported").With("value", value)
+		}
+		return Explicit, t, nil
+	}
+}
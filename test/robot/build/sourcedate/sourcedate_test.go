@@ -0,0 +1,59 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sourcedate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/gapid/core/log"
+)
+
+func TestResolve(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		value   string
+		mode    Mode
+		time    time.Time
+		wantErr bool
+	}{
+		{name: "empty defaults to Zero", value: "", mode: Zero, time: time.Unix(0, 0).UTC()},
+		{name: "explicit Zero", value: "Zero", mode: Zero, time: time.Unix(0, 0).UTC()},
+		{name: "SourceTimestamp", value: "SourceTimestamp", mode: SourceTimestamp},
+		{name: "BuildTimestamp", value: "BuildTimestamp", mode: BuildTimestamp},
+		{name: "RFC3339", value: "2020-01-02T15:04:05Z", mode: Explicit, time: time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{name: "garbage", value: "not-a-timestamp", wantErr: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := log.Testing(t)
+			mode, ts, err := Resolve(ctx, test.value)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Resolve(%q) succeeded, want error", test.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q) failed: %v", test.value, err)
+			}
+			if mode != test.mode {
+				t.Errorf("Resolve(%q) mode = %v, want %v", test.value, mode, test.mode)
+			}
+			if mode == Explicit && !ts.Equal(test.time) {
+				t.Errorf("Resolve(%q) time = %v, want %v", test.value, ts, test.time)
+			}
+		})
+	}
+}